@@ -0,0 +1,303 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	// contentIndexMaxFileBytes caps how much of a single file gets read
+	// into the inverted index.
+	contentIndexMaxFileBytes = 1 << 20 // 1 MiB
+	// contentIndexMaxTotalBytes caps total bytes indexed across all files
+	// so a directory full of large text files can't exhaust memory.
+	contentIndexMaxTotalBytes = 64 << 20 // 64 MiB
+)
+
+// contentIndexExtensions lists the file extensions eligible for the
+// opt-in content-indexing pass.
+var contentIndexExtensions = map[string]bool{
+	".txt":  true,
+	".md":   true,
+	".go":   true,
+	".json": true,
+	".csv":  true,
+}
+
+// ContentIndex is a token -> set-of-paths inverted index used by the
+// content=<phrase> search parameter. It is rebuilt from scratch whenever
+// buildIndex runs.
+type ContentIndex struct {
+	mu       sync.RWMutex
+	postings map[string]map[string]struct{}
+	used     int64
+}
+
+func NewContentIndex() *ContentIndex {
+	return &ContentIndex{postings: make(map[string]map[string]struct{})}
+}
+
+func (idx *ContentIndex) reset() {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.postings = make(map[string]map[string]struct{})
+	idx.used = 0
+}
+
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !(r == '_' || (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9'))
+	})
+}
+
+// index tokenizes content and adds relativePath to each token's posting
+// list, skipping the file once the global memory budget is exhausted.
+func (idx *ContentIndex) index(relativePath string, content []byte) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if idx.used >= contentIndexMaxTotalBytes {
+		return
+	}
+	idx.used += int64(len(content))
+
+	for _, token := range tokenize(string(content)) {
+		set, ok := idx.postings[token]
+		if !ok {
+			set = make(map[string]struct{})
+			idx.postings[token] = set
+		}
+		set[relativePath] = struct{}{}
+	}
+}
+
+// removePrefix drops relativePath, and everything indexed under it as a
+// directory, from every posting list, so a removed or overwritten file
+// doesn't keep matching stale content=<phrase> searches.
+func (idx *ContentIndex) removePrefix(relativePath string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	prefix := relativePath + "/"
+	for token, set := range idx.postings {
+		for path := range set {
+			if path == relativePath || strings.HasPrefix(path, prefix) {
+				delete(set, path)
+			}
+		}
+		if len(set) == 0 {
+			delete(idx.postings, token)
+		}
+	}
+}
+
+// search returns the relative paths whose indexed content contains every
+// token of phrase.
+func (idx *ContentIndex) search(phrase string) map[string]struct{} {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	tokens := tokenize(phrase)
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	result := make(map[string]struct{})
+	for path := range idx.postings[tokens[0]] {
+		result[path] = struct{}{}
+	}
+	for _, token := range tokens[1:] {
+		set := idx.postings[token]
+		for path := range result {
+			if _, ok := set[path]; !ok {
+				delete(result, path)
+			}
+		}
+	}
+
+	return result
+}
+
+// snippetContextBytes is how much text either side of the match is kept in
+// a search result's content snippet.
+const snippetContextBytes = 40
+
+// extractSnippet returns a whitespace-collapsed window of content around
+// the first case-insensitive occurrence of phrase, falling back to the
+// first occurrence of phrase's leading token if the exact phrase isn't
+// found contiguously (the posting-list search that produced this match
+// only requires every token to appear somewhere in the file, not in order).
+func extractSnippet(content []byte, phrase string) string {
+	lower := strings.ToLower(string(content))
+
+	target := strings.ToLower(phrase)
+	idx := strings.Index(lower, target)
+	if idx == -1 {
+		tokens := tokenize(phrase)
+		if len(tokens) == 0 {
+			return ""
+		}
+		target = tokens[0]
+		idx = strings.Index(lower, target)
+		if idx == -1 {
+			return ""
+		}
+	}
+
+	start := idx - snippetContextBytes
+	if start < 0 {
+		start = 0
+	}
+	end := idx + len(target) + snippetContextBytes
+	if end > len(content) {
+		end = len(content)
+	}
+
+	return strings.Join(strings.Fields(string(content[start:end])), " ")
+}
+
+// attachContentSnippets fills in ContentSnippet on each non-directory entry
+// of files by re-reading up to contentIndexMaxFileBytes of it and locating
+// phrase, so content=<phrase> search results show the matching context
+// instead of just the matching paths.
+func (s *Server) attachContentSnippets(files []FileInfo, phrase string) {
+	for i := range files {
+		if files[i].IsDir {
+			continue
+		}
+
+		reader, err := s.Storage.Open(files[i].RelativePath)
+		if err != nil {
+			continue
+		}
+
+		content := make([]byte, contentIndexMaxFileBytes)
+		n, err := io.ReadFull(reader, content)
+		reader.Close()
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			continue
+		}
+
+		files[i].ContentSnippet = extractSnippet(content[:n], phrase)
+	}
+}
+
+// isBinary detects binary content the same way git does: a NUL byte
+// anywhere in the first 512 bytes.
+func isBinary(data []byte) bool {
+	if len(data) > 512 {
+		data = data[:512]
+	}
+	return bytes.IndexByte(data, 0) != -1
+}
+
+// searchIgnoreMatcher answers whether a relative path should be skipped by
+// content indexing, based on .searchignore files found in that path's
+// directory and its ancestors, with the same glob semantics as a single
+// .gitignore line (filepath.Match against the base name).
+type searchIgnoreMatcher struct {
+	mu       sync.Mutex
+	patterns map[string][]string // relative directory -> patterns
+}
+
+func newSearchIgnoreMatcher() *searchIgnoreMatcher {
+	return &searchIgnoreMatcher{patterns: make(map[string][]string)}
+}
+
+func (m *searchIgnoreMatcher) reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.patterns = make(map[string][]string)
+}
+
+func (m *searchIgnoreMatcher) patternsFor(storage StorageProvider, dir string) []string {
+	m.mu.Lock()
+	if patterns, ok := m.patterns[dir]; ok {
+		m.mu.Unlock()
+		return patterns
+	}
+	m.mu.Unlock()
+
+	var patterns []string
+	reader, err := storage.Open(filepath.Join(dir, ".searchignore"))
+	if err == nil {
+		defer reader.Close()
+		scanner := bufio.NewScanner(reader)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			patterns = append(patterns, line)
+		}
+	}
+
+	m.mu.Lock()
+	m.patterns[dir] = patterns
+	m.mu.Unlock()
+
+	return patterns
+}
+
+func (m *searchIgnoreMatcher) ignored(storage StorageProvider, relativePath string) bool {
+	dir := filepath.Dir(relativePath)
+	if dir == "." {
+		dir = ""
+	}
+	name := filepath.Base(relativePath)
+
+	current := ""
+	dirs := []string{""}
+	for _, seg := range strings.Split(dir, string(filepath.Separator)) {
+		if seg == "" {
+			continue
+		}
+		current = filepath.Join(current, seg)
+		dirs = append(dirs, current)
+	}
+
+	for _, d := range dirs {
+		for _, pattern := range m.patternsFor(storage, d) {
+			if matched, _ := filepath.Match(pattern, name); matched {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// indexFileContent reads up to contentIndexMaxFileBytes of path, skips it
+// if it looks binary or is ignored, and adds it to idx.
+func indexFileContent(storage StorageProvider, idx *ContentIndex, ignore *searchIgnoreMatcher, fileInfo FileInfo) {
+	ext := strings.ToLower(fileInfo.Extension)
+	if !contentIndexExtensions[ext] {
+		return
+	}
+	if ignore.ignored(storage, fileInfo.RelativePath) {
+		return
+	}
+
+	reader, err := storage.Open(fileInfo.RelativePath)
+	if err != nil {
+		return
+	}
+	defer reader.Close()
+
+	content := make([]byte, contentIndexMaxFileBytes)
+	n, err := io.ReadFull(reader, content)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return
+	}
+	content = content[:n]
+
+	if isBinary(content) {
+		return
+	}
+
+	idx.index(fileInfo.RelativePath, content)
+}
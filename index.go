@@ -0,0 +1,139 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// fileIndexStore holds the live index behind a RWMutex, keyed by
+// RelativePath so creates/writes/renames/removes from fsnotify (or the
+// HTTP API) can be applied in O(1) instead of re-walking the tree.
+type fileIndexStore struct {
+	mu          sync.RWMutex
+	files       map[string]FileInfo
+	directories map[string]FileInfo
+	lastIndexed time.Time
+}
+
+func newFileIndexStore() *fileIndexStore {
+	return &fileIndexStore{
+		files:       make(map[string]FileInfo),
+		directories: make(map[string]FileInfo),
+	}
+}
+
+// snapshot renders the current state as the FileIndex shape the API and
+// frontend already expect.
+func (idx *fileIndexStore) snapshot() *FileIndex {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	index := &FileIndex{
+		Files:       make([]FileInfo, 0, len(idx.files)),
+		Directories: make([]FileInfo, 0, len(idx.directories)),
+		LastIndexed: idx.lastIndexed,
+	}
+	for _, f := range idx.files {
+		index.Files = append(index.Files, f)
+		index.TotalSize += f.Size
+	}
+	for _, d := range idx.directories {
+		index.Directories = append(index.Directories, d)
+	}
+	index.TotalFiles = len(index.Files)
+
+	return index
+}
+
+func (idx *fileIndexStore) reset() {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.files = make(map[string]FileInfo)
+	idx.directories = make(map[string]FileInfo)
+}
+
+func (idx *fileIndexStore) put(info FileInfo) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if info.IsDir {
+		idx.directories[info.RelativePath] = info
+	} else {
+		idx.files[info.RelativePath] = info
+	}
+}
+
+// removePrefix deletes relativePath and, if it was a directory, everything
+// that was indexed underneath it.
+func (idx *fileIndexStore) removePrefix(relativePath string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	delete(idx.files, relativePath)
+	delete(idx.directories, relativePath)
+
+	prefix := relativePath + "/"
+	for path := range idx.files {
+		if strings.HasPrefix(path, prefix) {
+			delete(idx.files, path)
+		}
+	}
+	for path := range idx.directories {
+		if strings.HasPrefix(path, prefix) {
+			delete(idx.directories, path)
+		}
+	}
+}
+
+func (idx *fileIndexStore) touch() {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.lastIndexed = time.Now()
+}
+
+// IndexEvent describes a single change applied to the index, streamed to
+// the frontend over the /api/index/events SSE endpoint.
+type IndexEvent struct {
+	Type string    `json:"type"` // "create", "write", "remove"
+	Path string    `json:"path"`
+	Info *FileInfo `json:"info,omitempty"`
+}
+
+// indexEventBroadcaster fans out index events to any number of SSE
+// subscribers without blocking the watch loop on a slow client.
+type indexEventBroadcaster struct {
+	mu          sync.Mutex
+	subscribers map[chan IndexEvent]struct{}
+}
+
+func newIndexEventBroadcaster() *indexEventBroadcaster {
+	return &indexEventBroadcaster{subscribers: make(map[chan IndexEvent]struct{})}
+}
+
+func (b *indexEventBroadcaster) subscribe() chan IndexEvent {
+	ch := make(chan IndexEvent, 16)
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+	return ch
+}
+
+func (b *indexEventBroadcaster) unsubscribe(ch chan IndexEvent) {
+	b.mu.Lock()
+	delete(b.subscribers, ch)
+	b.mu.Unlock()
+	close(ch)
+}
+
+func (b *indexEventBroadcaster) publish(event IndexEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			// Slow subscriber; drop the event rather than blocking the
+			// watch loop. The client can always GET /api/index to resync.
+		}
+	}
+}
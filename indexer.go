@@ -0,0 +1,186 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/gin-gonic/gin"
+)
+
+// startIndexWatcher seeds the index with an initial walk of every storage
+// mount, then watches each disk-backed mount with fsnotify so further
+// creates/writes/renames/removes update the index incrementally instead of
+// triggering a full re-walk. Remote mounts (e.g. S3) have no local
+// directory to watch and fall back to the manual POST /api/index/rebuild
+// escape hatch.
+func (s *Server) startIndexWatcher() error {
+	s.buildIndex()
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	s.Watcher = watcher
+
+	multi, ok := s.Storage.(*MultiProvider)
+	if !ok {
+		log.Println("Storage provider does not expose mounts; falling back to manual index rebuilds")
+		return nil
+	}
+
+	for _, mount := range multi.Mounts() {
+		disk, ok := mount.Provider.(*DiskProvider)
+		if !ok {
+			continue
+		}
+		if err := addWatchRecursive(watcher, disk.Root); err != nil {
+			log.Printf("Failed to watch %s: %v", disk.Root, err)
+			continue
+		}
+		go s.watchLoop(watcher, disk, mount.Mount)
+	}
+
+	return nil
+}
+
+func addWatchRecursive(watcher *fsnotify.Watcher, root string) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if werr := watcher.Add(path); werr != nil {
+				log.Printf("Failed to watch directory %s: %v", path, werr)
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Server) watchLoop(watcher *fsnotify.Watcher, disk *DiskProvider, mount string) {
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			s.handleFSEvent(watcher, disk, mount, event)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("fsnotify error: %v", err)
+		}
+	}
+}
+
+func (s *Server) handleFSEvent(watcher *fsnotify.Watcher, disk *DiskProvider, mount string, event fsnotify.Event) {
+	relativePath, err := filepath.Rel(disk.Root, event.Name)
+	if err != nil || relativePath == "." {
+		return
+	}
+	relativePath = prefixWithMount(mount, relativePath)
+
+	switch {
+	case event.Op&(fsnotify.Remove|fsnotify.Rename) != 0:
+		s.Index.removePrefix(relativePath)
+		if s.IndexContent {
+			s.ContentIndex.removePrefix(relativePath)
+		}
+		s.Events.publish(IndexEvent{Type: "remove", Path: relativePath})
+
+	case event.Op&(fsnotify.Create|fsnotify.Write) != 0:
+		info, err := getFileInfo(event.Name, filepath.Base(event.Name), relativePath)
+		if err != nil {
+			return
+		}
+		info.Provider = disk.Name()
+		s.Index.put(info)
+
+		if s.IndexContent && !info.IsDir {
+			// A write can change or shrink the content that was previously
+			// indexed, so drop the old postings before re-indexing.
+			s.ContentIndex.removePrefix(relativePath)
+			indexFileContent(s.Storage, s.ContentIndex, s.SearchIgnore, info)
+		}
+
+		eventType := "write"
+		if event.Op&fsnotify.Create != 0 {
+			eventType = "create"
+		}
+		s.Events.publish(IndexEvent{Type: eventType, Path: relativePath, Info: &info})
+
+		if info.IsDir && event.Op&fsnotify.Create != 0 {
+			// A directory can appear via mkdir (empty, nothing further to
+			// do) or via a move that brings existing children with it, so
+			// watch it and re-index whatever is already inside.
+			if werr := addWatchRecursive(watcher, event.Name); werr != nil {
+				log.Printf("Failed to watch new directory %s: %v", event.Name, werr)
+			}
+			s.reindexSubtree(disk, mount, event.Name, relativePath)
+		}
+	}
+
+	s.Index.touch()
+}
+
+// reindexSubtree re-indexes everything under fullRoot after a directory is
+// created or moved into place. relativeRoot is the already mount-prefixed
+// path handleFSEvent computed for fullRoot, used only to skip re-indexing
+// the root entry itself (already indexed by the caller).
+func (s *Server) reindexSubtree(disk *DiskProvider, mount, fullRoot, relativeRoot string) {
+	err := filepath.Walk(fullRoot, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		rel, relErr := filepath.Rel(disk.Root, path)
+		if relErr != nil {
+			return nil
+		}
+		relativePath := prefixWithMount(mount, rel)
+		if relativePath == relativeRoot {
+			return nil
+		}
+
+		childInfo, infoErr := getFileInfo(path, info.Name(), relativePath)
+		if infoErr != nil {
+			return nil
+		}
+		childInfo.Provider = disk.Name()
+		s.Index.put(childInfo)
+		if s.IndexContent && !childInfo.IsDir {
+			indexFileContent(s.Storage, s.ContentIndex, s.SearchIgnore, childInfo)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to re-index %s: %v", fullRoot, err)
+	}
+}
+
+// streamIndexEvents is a GET /api/index/events SSE endpoint so the
+// frontend can live-update instead of polling GET /api/index.
+func (s *Server) streamIndexEvents(c *gin.Context) {
+	ch := s.Events.subscribe()
+	defer s.Events.unsubscribe(ch)
+
+	c.Writer.Header().Set("Content-Type", "text/event-stream")
+	c.Writer.Header().Set("Cache-Control", "no-cache")
+	c.Writer.Header().Set("Connection", "keep-alive")
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-ch:
+			if !ok {
+				return false
+			}
+			c.SSEvent("change", event)
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
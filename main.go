@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
@@ -11,6 +12,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
@@ -24,6 +26,10 @@ type User struct {
 	Username  string    `json:"username" gorm:"unique;not null"`
 	Password  string    `json:"-" gorm:"not null"`
 	IsAdmin   bool      `json:"isAdmin" gorm:"default:false"`
+	// Role drives requirePermission's role-based grants (see permissions.go).
+	// IsAdmin is kept for backward compatibility and still bypasses
+	// permission checks entirely, regardless of Role.
+	Role      string    `json:"role" gorm:"default:viewer"`
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
 }
@@ -37,6 +43,7 @@ type CreateUserRequest struct {
 	Username string `json:"username" binding:"required"`
 	Password string `json:"password" binding:"required,min=6"`
 	IsAdmin  bool   `json:"isAdmin"`
+	Role     string `json:"role"`
 }
 
 type ChangePasswordRequest struct {
@@ -48,6 +55,12 @@ type Claims struct {
 	UserID   uint   `json:"userId"`
 	Username string `json:"username"`
 	IsAdmin  bool   `json:"isAdmin"`
+	Role     string `json:"role"`
+	// ShareID and Scope are set only on short-lived tokens minted by
+	// unlockShare; Scope is "share" for those and empty for normal
+	// user logins.
+	ShareID uint   `json:"shareId,omitempty"`
+	Scope   string `json:"scope,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -61,6 +74,10 @@ type FileInfo struct {
 	RelativePath string    `json:"relativePath"`
 	IsSymlink    bool      `json:"isSymlink"`
 	LinkTarget   string    `json:"linkTarget,omitempty"`
+	Provider     string    `json:"provider"`
+	// ContentSnippet is populated only by search's content=<phrase>
+	// parameter, showing the matching context around the phrase.
+	ContentSnippet string `json:"contentSnippet,omitempty"`
 }
 
 type FileIndex struct {
@@ -72,10 +89,20 @@ type FileIndex struct {
 }
 
 type Server struct {
-	ServeDir string
-	Index    *FileIndex
-	DB       *gorm.DB
-	JWTKey   []byte
+	ServeDir       string
+	Storage        StorageProvider
+	Index          *fileIndexStore
+	Events         *indexEventBroadcaster
+	Watcher        *fsnotify.Watcher
+	ContentIndex   *ContentIndex
+	SearchIgnore   *searchIgnoreMatcher
+	IndexContent   bool
+	UploadTempDir  string
+	MaxUploadSize  int64
+	UserQuotaBytes int64
+	UploadTTL      time.Duration
+	DB             *gorm.DB
+	JWTKey         []byte
 }
 
 func main() {
@@ -105,7 +132,7 @@ func main() {
 	}
 
 	// Auto-migrate the schema
-	err = db.AutoMigrate(&User{})
+	err = db.AutoMigrate(&User{}, &Share{}, &Upload{}, &Permission{})
 	if err != nil {
 		log.Fatal("Failed to migrate database:", err)
 	}
@@ -117,19 +144,73 @@ func main() {
 		log.Println("Warning: Using default JWT secret. Set JWT_SECRET environment variable in production.")
 	}
 
+	// Load storage provider config, falling back to a single disk provider
+	// rooted at serveDir when no providers.yml is present.
+	providersConfigPath := os.Getenv("PROVIDERS_CONFIG")
+	if providersConfigPath == "" {
+		providersConfigPath = "providers.yml"
+	}
+
+	providersCfg, err := loadProvidersConfig(providersConfigPath, serveDir)
+	if err != nil {
+		log.Fatal("Failed to load storage providers config:", err)
+	}
+
+	storage, err := buildStorage(context.Background(), providersCfg)
+	if err != nil {
+		log.Fatal("Failed to initialize storage providers:", err)
+	}
+
+	uploadTempDir := os.Getenv("UPLOAD_TMP_DIR")
+	if uploadTempDir == "" {
+		uploadTempDir = "./tmp/uploads"
+	}
+	if err := os.MkdirAll(uploadTempDir, 0755); err != nil {
+		log.Fatal("Failed to create upload temp directory:", err)
+	}
+
+	var maxUploadSize int64
+	if v := os.Getenv("MAX_UPLOAD_SIZE"); v != "" {
+		maxUploadSize, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			log.Fatal("Invalid MAX_UPLOAD_SIZE:", err)
+		}
+	}
+
+	var userQuotaBytes int64
+	if v := os.Getenv("USER_QUOTA_BYTES"); v != "" {
+		userQuotaBytes, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			log.Fatal("Invalid USER_QUOTA_BYTES:", err)
+		}
+	}
+
 	server := &Server{
-		ServeDir: serveDir,
-		DB:       db,
-		JWTKey:   jwtKey,
+		ServeDir:       serveDir,
+		Storage:        storage,
+		Index:          newFileIndexStore(),
+		Events:         newIndexEventBroadcaster(),
+		ContentIndex:   NewContentIndex(),
+		SearchIgnore:   newSearchIgnoreMatcher(),
+		IndexContent:   os.Getenv("INDEX_CONTENT") == "true",
+		UploadTempDir:  uploadTempDir,
+		MaxUploadSize:  maxUploadSize,
+		UserQuotaBytes: userQuotaBytes,
+		UploadTTL:      24 * time.Hour,
+		DB:             db,
+		JWTKey:         jwtKey,
 	}
 
 	// Create default admin user if none exists
 	server.createDefaultAdmin()
 
-	// Build initial index
+	// Build the initial index and start watching for changes
 	log.Printf("Indexing directory: %s", serveDir)
-	server.buildIndex()
-	log.Printf("Indexed %d files and %d directories", len(server.Index.Files), len(server.Index.Directories))
+	if err := server.startIndexWatcher(); err != nil {
+		log.Printf("Failed to start index watcher, falling back to manual rebuilds: %v", err)
+	}
+	snapshot := server.Index.snapshot()
+	log.Printf("Indexed %d files and %d directories", len(snapshot.Files), len(snapshot.Directories))
 
 	r := gin.Default()
 
@@ -159,20 +240,53 @@ func main() {
 		{
 			protected.GET("/index", server.getIndex)
 			protected.POST("/index/rebuild", server.rebuildIndex)
-			protected.GET("/browse/*path", server.browsePath)
-			protected.GET("/download/*path", server.downloadFile)
-			protected.POST("/upload/*path", server.uploadFile)
-			protected.PUT("/rename/*path", server.renameFile)
-			protected.DELETE("/delete/*path", server.deleteFile)
-			protected.POST("/mkdir/*path", server.createDirectory)
-			
+			protected.GET("/index/events", server.streamIndexEvents)
+			protected.GET("/search", server.search)
+
+			// Resumable, tus-style upload routes
+			protected.POST("/uploads", server.createUpload)
+			protected.PATCH("/uploads/:id", server.patchUpload)
+			protected.HEAD("/uploads/:id", server.headUpload)
+			protected.DELETE("/uploads/:id", server.deleteUpload)
+			protected.GET("/browse/*path", server.requirePermission(PermRead), server.browsePath)
+			protected.GET("/download/*path", server.requirePermission(PermRead), server.downloadFile)
+			protected.PUT("/rename/*path", server.requirePermission(PermWrite), server.renameFile)
+			protected.DELETE("/delete/*path", server.requirePermission(PermDelete), server.deleteFile)
+			protected.POST("/mkdir/*path", server.requirePermission(PermWrite), server.createDirectory)
+
 			// User management routes (admin only)
 			protected.POST("/users", server.requireAdmin(), server.createUser)
 			protected.GET("/users", server.requireAdmin(), server.getUsers)
 			protected.DELETE("/users/:id", server.requireAdmin(), server.deleteUser)
 			protected.GET("/me", server.getCurrentUser)
 			protected.PUT("/me/password", server.changePassword)
+
+			// Share management routes
+			protected.POST("/files/share", server.createShare)
+			protected.GET("/shares", server.getShares)
+			protected.PATCH("/shares/:id", server.updateShare)
+			protected.DELETE("/shares/:id", server.deleteShare)
+
+			// Permission management routes (admin only), plus a
+			// self-service endpoint so the frontend can hide actions the
+			// caller isn't allowed to take.
+			protected.POST("/permissions", server.requireAdmin(), server.createPermission)
+			protected.GET("/permissions", server.requireAdmin(), server.getPermissions)
+			protected.DELETE("/permissions/:id", server.requireAdmin(), server.deletePermission)
+			protected.GET("/me/permissions", server.getMyPermissions)
 		}
+
+		// Public share routes (no authentication required). The plain
+		// "/s/:token" and "/s/:token/download" routes serve the shared path
+		// itself; the "*path" variants let a visitor navigate into, download
+		// from, or (when the share is Writable) mutate a subdirectory share.
+		api.GET("/s/:token", server.getShare)
+		api.GET("/s/:token/browse/*path", server.getShare)
+		api.GET("/s/:token/download", server.downloadShare)
+		api.GET("/s/:token/download/*path", server.downloadShare)
+		api.POST("/s/:token/upload/*path", server.uploadToShare)
+		api.DELETE("/s/:token/delete/*path", server.deleteFromShare)
+		api.POST("/s/:token/unlock", server.unlockShare)
 	}
 
 	// Catch-all route for React Router
@@ -253,34 +367,17 @@ func getFileInfo(path string, name string, relativePath string) (FileInfo, error
 }
 
 func (s *Server) buildIndex() {
-	index := &FileIndex{
-		Files:       make([]FileInfo, 0),
-		Directories: make([]FileInfo, 0),
-		LastIndexed: time.Now(),
+	s.Index.reset()
+	if s.IndexContent {
+		s.ContentIndex.reset()
+		s.SearchIgnore.reset()
 	}
 
-	err := filepath.Walk(s.ServeDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil // Skip files with errors
-		}
-
-		relativePath, _ := filepath.Rel(s.ServeDir, path)
-		if relativePath == "." {
-			return nil // Skip root directory
+	err := s.Storage.Walk("", func(fileInfo FileInfo) error {
+		s.Index.put(fileInfo)
+		if s.IndexContent && !fileInfo.IsDir {
+			indexFileContent(s.Storage, s.ContentIndex, s.SearchIgnore, fileInfo)
 		}
-
-		fileInfo, err := getFileInfo(path, info.Name(), relativePath)
-		if err != nil {
-			return nil // Skip files with errors
-		}
-
-		if fileInfo.IsDir {
-			index.Directories = append(index.Directories, fileInfo)
-		} else {
-			index.Files = append(index.Files, fileInfo)
-			index.TotalSize += fileInfo.Size
-		}
-
 		return nil
 	})
 
@@ -288,12 +385,18 @@ func (s *Server) buildIndex() {
 		log.Printf("Error building index: %v", err)
 	}
 
-	index.TotalFiles = len(index.Files)
-	s.Index = index
+	s.Index.touch()
 }
 
 func (s *Server) getIndex(c *gin.Context) {
-	c.JSON(http.StatusOK, s.Index)
+	user, _ := c.Get("user")
+	claims := user.(*Claims)
+
+	snapshot := s.Index.snapshot()
+	snapshot.Files = s.filterReadable(claims, snapshot.Files)
+	snapshot.Directories = s.filterReadable(claims, snapshot.Directories)
+
+	c.JSON(http.StatusOK, snapshot)
 }
 
 func (s *Server) rebuildIndex(c *gin.Context) {
@@ -309,43 +412,26 @@ func (s *Server) browsePath(c *gin.Context) {
 		requestPath = strings.TrimPrefix(requestPath, "/")
 	}
 
-	fullPath := filepath.Join(s.ServeDir, requestPath)
-
-	// Security check - ensure path is within serve directory
-	if !strings.HasPrefix(fullPath, s.ServeDir) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
-		return
-	}
-
-	stat, err := os.Stat(fullPath)
+	stat, err := s.Storage.Stat(requestPath)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Path not found"})
 		return
 	}
 
-	if !stat.IsDir() {
+	if !stat.IsDir {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Path is not a directory"})
 		return
 	}
 
-	entries, err := os.ReadDir(fullPath)
+	files, err := s.Storage.List(requestPath)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read directory"})
 		return
 	}
 
-	var files []FileInfo
-	for _, entry := range entries {
-		relativePath := filepath.Join(requestPath, entry.Name())
-		entryPath := filepath.Join(fullPath, entry.Name())
-		
-		fileInfo, err := getFileInfo(entryPath, entry.Name(), relativePath)
-		if err != nil {
-			continue // Skip files with errors
-		}
-		
-		files = append(files, fileInfo)
-	}
+	user, _ := c.Get("user")
+	claims := user.(*Claims)
+	files = s.filterReadable(claims, files)
 
 	c.JSON(http.StatusOK, gin.H{
 		"path":  requestPath,
@@ -355,78 +441,36 @@ func (s *Server) browsePath(c *gin.Context) {
 
 func (s *Server) downloadFile(c *gin.Context) {
 	requestPath := strings.TrimPrefix(c.Param("path"), "/")
-	fullPath := filepath.Join(s.ServeDir, requestPath)
-
-	if !strings.HasPrefix(fullPath, s.ServeDir) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
-		return
-	}
 
-	stat, err := os.Stat(fullPath)
+	stat, err := s.Storage.Stat(requestPath)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
 		return
 	}
 
-	if stat.IsDir() {
+	if stat.IsDir {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot download directory"})
 		return
 	}
 
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filepath.Base(fullPath)))
-	c.File(fullPath)
-}
-
-func (s *Server) uploadFile(c *gin.Context) {
-	requestPath := strings.TrimPrefix(c.Param("path"), "/")
-	targetDir := filepath.Join(s.ServeDir, requestPath)
-
-	if !strings.HasPrefix(targetDir, s.ServeDir) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
-		return
-	}
-
-	file, header, err := c.Request.FormFile("file")
+	reader, err := s.Storage.Open(requestPath)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No file provided"})
-		return
-	}
-	defer file.Close()
-
-	// Ensure target directory exists
-	if err := os.MkdirAll(targetDir, 0755); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create directory"})
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
 		return
 	}
+	defer reader.Close()
 
-	targetPath := filepath.Join(targetDir, header.Filename)
-	out, err := os.Create(targetPath)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create file"})
-		return
-	}
-	defer out.Close()
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filepath.Base(requestPath)))
+	c.Header("Content-Length", strconv.FormatInt(stat.Size, 10))
+	c.Status(http.StatusOK)
 
-	_, err = io.Copy(out, file)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
-		return
+	if _, err := io.Copy(c.Writer, reader); err != nil {
+		log.Printf("Error streaming download of %s: %v", requestPath, err)
 	}
-
-	// Rebuild index after upload
-	go s.buildIndex()
-
-	c.JSON(http.StatusOK, gin.H{"message": "File uploaded successfully"})
 }
 
 func (s *Server) renameFile(c *gin.Context) {
 	requestPath := strings.TrimPrefix(c.Param("path"), "/")
-	fullPath := filepath.Join(s.ServeDir, requestPath)
-
-	if !strings.HasPrefix(fullPath, s.ServeDir) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
-		return
-	}
 
 	var body struct {
 		NewName string `json:"newName"`
@@ -437,41 +481,43 @@ func (s *Server) renameFile(c *gin.Context) {
 		return
 	}
 
-	newPath := filepath.Join(filepath.Dir(fullPath), body.NewName)
-	if err := os.Rename(fullPath, newPath); err != nil {
+	newPath := filepath.Join(filepath.Dir(requestPath), body.NewName)
+
+	user, _ := c.Get("user")
+	claims := user.(*Claims)
+	if !s.hasPermission(claims, newPath, PermWrite) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions for this path"})
+		return
+	}
+
+	if err := s.Storage.Rename(requestPath, newPath); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rename file"})
 		return
 	}
 
-	// Rebuild index after rename
-	go s.buildIndex()
+	// The fsnotify watcher updates the index incrementally; see
+	// startIndexWatcher.
 
 	c.JSON(http.StatusOK, gin.H{"message": "File renamed successfully"})
 }
 
 func (s *Server) deleteFile(c *gin.Context) {
 	requestPath := strings.TrimPrefix(c.Param("path"), "/")
-	fullPath := filepath.Join(s.ServeDir, requestPath)
-
-	if !strings.HasPrefix(fullPath, s.ServeDir) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
-		return
-	}
 
-	if err := os.RemoveAll(fullPath); err != nil {
+	if err := s.Storage.Remove(requestPath); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete file"})
 		return
 	}
 
-	// Rebuild index after delete
-	go s.buildIndex()
+	// The fsnotify watcher updates the index incrementally; see
+	// startIndexWatcher.
 
 	c.JSON(http.StatusOK, gin.H{"message": "File deleted successfully"})
 }
 
 func (s *Server) createDirectory(c *gin.Context) {
 	requestPath := strings.TrimPrefix(c.Param("path"), "/")
-	
+
 	var body struct {
 		Name string `json:"name"`
 	}
@@ -481,20 +527,22 @@ func (s *Server) createDirectory(c *gin.Context) {
 		return
 	}
 
-	fullPath := filepath.Join(s.ServeDir, requestPath, body.Name)
+	targetPath := filepath.Join(requestPath, body.Name)
 
-	if !strings.HasPrefix(fullPath, s.ServeDir) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+	user, _ := c.Get("user")
+	claims := user.(*Claims)
+	if !s.hasPermission(claims, targetPath, PermWrite) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions for this path"})
 		return
 	}
 
-	if err := os.MkdirAll(fullPath, 0755); err != nil {
+	if err := s.Storage.Mkdir(targetPath); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create directory"})
 		return
 	}
 
-	// Rebuild index after directory creation
-	go s.buildIndex()
+	// The fsnotify watcher updates the index incrementally; see
+	// startIndexWatcher.
 
 	c.JSON(http.StatusOK, gin.H{"message": "Directory created successfully"})
 }
@@ -520,6 +568,7 @@ func (s *Server) createDefaultAdmin() {
 			Username: "admin",
 			Password: string(hashedPassword),
 			IsAdmin:  true,
+			Role:     "admin",
 		}
 		
 		result := s.DB.Create(&admin)
@@ -536,6 +585,7 @@ func (s *Server) generateToken(user *User) (string, error) {
 		UserID:   user.ID,
 		Username: user.Username,
 		IsAdmin:  user.IsAdmin,
+		Role:     user.Role,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -570,7 +620,16 @@ func (s *Server) authMiddleware() gin.HandlerFunc {
 			c.Abort()
 			return
 		}
-		
+
+		// Share-scoped tokens minted by unlockShare are only valid against
+		// the public /s/:token* routes, which don't use this middleware; a
+		// normal user token is required for everything behind authMiddleware.
+		if claims.Scope == "share" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			c.Abort()
+			return
+		}
+
 		c.Set("user", claims)
 		c.Next()
 	}
@@ -656,13 +715,26 @@ func (s *Server) createUser(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
 		return
 	}
-	
+
+	role := req.Role
+	if role == "" {
+		role = "viewer"
+	}
+	if req.IsAdmin {
+		role = "admin"
+	}
+	if !validRoles[role] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+		return
+	}
+
 	user := User{
 		Username: req.Username,
 		Password: string(hashedPassword),
 		IsAdmin:  req.IsAdmin,
+		Role:     role,
 	}
-	
+
 	result := s.DB.Create(&user)
 	if result.Error != nil {
 		if strings.Contains(result.Error.Error(), "UNIQUE constraint failed") {
@@ -672,11 +744,12 @@ func (s *Server) createUser(c *gin.Context) {
 		}
 		return
 	}
-	
+
 	c.JSON(http.StatusCreated, gin.H{
-		"id":       user.ID,
-		"username": user.Username,
-		"isAdmin":  user.IsAdmin,
+		"id":        user.ID,
+		"username":  user.Username,
+		"isAdmin":   user.IsAdmin,
+		"role":      user.Role,
 		"createdAt": user.CreatedAt,
 	})
 }
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PermissionBits is a bitmask of the actions a Permission grant covers.
+type PermissionBits uint8
+
+const (
+	PermRead PermissionBits = 1 << iota
+	PermWrite
+	PermDelete
+	PermShare
+)
+
+// validRoles lists the roles a User or Permission grant may reference.
+var validRoles = map[string]bool{
+	"viewer": true,
+	"editor": true,
+	"admin":  true,
+}
+
+// Permission grants (or denies) a bitmask of actions on everything under
+// PathPrefix to either a specific user (UserID) or everyone with a given
+// Role, never both. hasPermission resolves the longest matching PathPrefix
+// across all applicable grants, preferring user grants over role grants and
+// letting any Deny grant in the winning set override an allow.
+type Permission struct {
+	ID         uint           `json:"id" gorm:"primaryKey"`
+	UserID     *uint          `json:"userId"`
+	Role       string         `json:"role"`
+	PathPrefix string         `json:"pathPrefix" gorm:"not null"`
+	Bits       PermissionBits `json:"bits"`
+	Deny       bool           `json:"deny" gorm:"default:false"`
+	CreatedAt  time.Time      `json:"createdAt"`
+}
+
+type CreatePermissionRequest struct {
+	UserID     *uint  `json:"userId"`
+	Role       string `json:"role"`
+	PathPrefix string `json:"pathPrefix" binding:"required"`
+	Read       bool   `json:"read"`
+	Write      bool   `json:"write"`
+	Delete     bool   `json:"delete"`
+	Share      bool   `json:"share"`
+	Deny       bool   `json:"deny"`
+}
+
+func (s *Server) createPermission(c *gin.Context) {
+	var req CreatePermissionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if (req.UserID == nil) == (req.Role == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Exactly one of userId or role must be set"})
+		return
+	}
+	if req.Role != "" && !validRoles[req.Role] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+		return
+	}
+
+	var bits PermissionBits
+	if req.Read {
+		bits |= PermRead
+	}
+	if req.Write {
+		bits |= PermWrite
+	}
+	if req.Delete {
+		bits |= PermDelete
+	}
+	if req.Share {
+		bits |= PermShare
+	}
+
+	permission := Permission{
+		UserID:     req.UserID,
+		Role:       req.Role,
+		PathPrefix: trimSlashes(req.PathPrefix),
+		Bits:       bits,
+		Deny:       req.Deny,
+	}
+
+	if result := s.DB.Create(&permission); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create permission"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, permission)
+}
+
+func (s *Server) getPermissions(c *gin.Context) {
+	var permissions []Permission
+	if result := s.DB.Find(&permissions); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch permissions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, permissions)
+}
+
+func (s *Server) deletePermission(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid permission ID"})
+		return
+	}
+
+	result := s.DB.Delete(&Permission{}, id)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete permission"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Permission not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Permission deleted successfully"})
+}
+
+// getMyPermissions returns the grants that apply to the caller, either
+// directly (UserID) or via their Role, so the frontend can hide actions the
+// caller isn't allowed to take.
+func (s *Server) getMyPermissions(c *gin.Context) {
+	user, _ := c.Get("user")
+	claims := user.(*Claims)
+
+	var permissions []Permission
+	result := s.DB.Where("user_id = ? OR role = ?", claims.UserID, claims.Role).Find(&permissions)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch permissions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, permissions)
+}
+
+// requirePermission builds middleware that requires the caller hold
+// required on c.Param("path"). Admins always bypass this check.
+func (s *Server) requirePermission(required PermissionBits) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, exists := c.Get("user")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			c.Abort()
+			return
+		}
+
+		claims, ok := user.(*Claims)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			c.Abort()
+			return
+		}
+		if claims.IsAdmin {
+			c.Next()
+			return
+		}
+
+		path := strings.TrimPrefix(c.Param("path"), "/")
+		if !s.hasPermission(claims, path, required) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions for this path"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// hasPermission resolves the grants applicable to claims on path, picking
+// the longest-matching PathPrefix, with user grants taking precedence over
+// role grants at equal specificity, and any Deny grant among the winners
+// overriding an allow.
+func (s *Server) hasPermission(claims *Claims, path string, required PermissionBits) bool {
+	if claims.IsAdmin {
+		return true
+	}
+
+	var grants []Permission
+	if result := s.DB.Where("user_id = ? OR role = ?", claims.UserID, claims.Role).Find(&grants); result.Error != nil {
+		return false
+	}
+
+	path = trimSlashes(path)
+
+	var bestLen = -1
+	var bestIsUser bool
+	var bits PermissionBits
+	var deny bool
+	matched := false
+
+	for _, grant := range grants {
+		if !pathHasMountPrefix(path, grant.PathPrefix) {
+			continue
+		}
+		isUser := grant.UserID != nil
+
+		if !matched ||
+			len(grant.PathPrefix) > bestLen ||
+			(len(grant.PathPrefix) == bestLen && isUser && !bestIsUser) {
+			matched = true
+			bestLen = len(grant.PathPrefix)
+			bestIsUser = isUser
+			bits = grant.Bits
+			deny = grant.Deny
+		} else if len(grant.PathPrefix) == bestLen && isUser == bestIsUser {
+			// Same specificity and same grant kind: merge bits, but a deny
+			// here still wins regardless of order.
+			bits |= grant.Bits
+			deny = deny || grant.Deny
+		}
+	}
+
+	if !matched || deny {
+		return false
+	}
+
+	return bits&required == required
+}
+
+// filterReadable narrows files down to the entries claims can read, used by
+// browsePath and getIndex so listings never leak paths the caller has no
+// access to.
+func (s *Server) filterReadable(claims *Claims, files []FileInfo) []FileInfo {
+	if claims.IsAdmin {
+		return files
+	}
+
+	readable := make([]FileInfo, 0, len(files))
+	for _, f := range files {
+		if s.hasPermission(claims, f.RelativePath, PermRead) {
+			readable = append(readable, f)
+		}
+	}
+	return readable
+}
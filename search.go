@@ -0,0 +1,176 @@
+package main
+
+import (
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// search handles GET /api/search, filtering the in-memory index by name,
+// extension, size, modification time, and directory-ness, with optional
+// glob/regex name matching and a content=<phrase> lookup against the
+// opt-in content index.
+func (s *Server) search(c *gin.Context) {
+	user, _ := c.Get("user")
+	claims := user.(*Claims)
+
+	q := c.Query("q")
+	ext := strings.ToLower(c.Query("ext"))
+	contentPhrase := c.Query("content")
+	sortBy := c.DefaultQuery("sort", "name")
+	order := c.DefaultQuery("order", "asc")
+
+	var wantDir *bool
+	if v := c.Query("isDir"); v != "" {
+		b := v == "true"
+		wantDir = &b
+	}
+
+	var nameRegex *regexp.Regexp
+	if c.Query("regex") == "true" && q != "" {
+		re, err := regexp.Compile(q)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid regex in q"})
+			return
+		}
+		nameRegex = re
+	}
+
+	minSize, hasMinSize := parseQueryInt64(c, "minSize")
+	maxSize, hasMaxSize := parseQueryInt64(c, "maxSize")
+	modifiedAfter, hasModifiedAfter := parseQueryTime(c, "modifiedAfter")
+	modifiedBefore, hasModifiedBefore := parseQueryTime(c, "modifiedBefore")
+
+	var contentMatches map[string]struct{}
+	if contentPhrase != "" {
+		contentMatches = s.ContentIndex.search(contentPhrase)
+	}
+
+	snapshot := s.Index.snapshot()
+	candidates := make([]FileInfo, 0, len(snapshot.Files)+len(snapshot.Directories))
+	candidates = append(candidates, s.filterReadable(claims, snapshot.Files)...)
+	candidates = append(candidates, s.filterReadable(claims, snapshot.Directories)...)
+
+	matched := make([]FileInfo, 0, len(candidates))
+	for _, f := range candidates {
+		if wantDir != nil && f.IsDir != *wantDir {
+			continue
+		}
+		if ext != "" && strings.ToLower(f.Extension) != ext {
+			continue
+		}
+		if hasMinSize && f.Size < minSize {
+			continue
+		}
+		if hasMaxSize && f.Size > maxSize {
+			continue
+		}
+		if hasModifiedAfter && f.ModTime.Before(modifiedAfter) {
+			continue
+		}
+		if hasModifiedBefore && f.ModTime.After(modifiedBefore) {
+			continue
+		}
+		if q != "" && !matchesNameQuery(f, q, nameRegex) {
+			continue
+		}
+		if contentPhrase != "" {
+			if _, ok := contentMatches[f.RelativePath]; !ok {
+				continue
+			}
+		}
+
+		matched = append(matched, f)
+	}
+
+	sortFiles(matched, sortBy, order)
+	total := len(matched)
+
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > len(matched) {
+		offset = len(matched)
+	}
+	matched = matched[offset:]
+
+	if limit, err := strconv.Atoi(c.Query("limit")); err == nil && limit >= 0 && limit < len(matched) {
+		matched = matched[:limit]
+	}
+
+	if contentPhrase != "" {
+		s.attachContentSnippets(matched, contentPhrase)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"files": matched,
+		"total": total,
+	})
+}
+
+func matchesNameQuery(f FileInfo, q string, nameRegex *regexp.Regexp) bool {
+	if nameRegex != nil {
+		return nameRegex.MatchString(f.Name) || nameRegex.MatchString(f.RelativePath)
+	}
+
+	if strings.ContainsAny(q, "*?[") {
+		if matched, _ := filepath.Match(q, f.Name); matched {
+			return true
+		}
+		matched, _ := filepath.Match(q, f.RelativePath)
+		return matched
+	}
+
+	q = strings.ToLower(q)
+	return strings.Contains(strings.ToLower(f.Name), q) || strings.Contains(strings.ToLower(f.RelativePath), q)
+}
+
+func sortFiles(files []FileInfo, sortBy, order string) {
+	sort.Slice(files, func(i, j int) bool {
+		switch sortBy {
+		case "size":
+			return files[i].Size < files[j].Size
+		case "modTime":
+			return files[i].ModTime.Before(files[j].ModTime)
+		default:
+			return files[i].Name < files[j].Name
+		}
+	})
+
+	if order == "desc" {
+		for i, j := 0, len(files)-1; i < j; i, j = i+1, j-1 {
+			files[i], files[j] = files[j], files[i]
+		}
+	}
+}
+
+func parseQueryInt64(c *gin.Context, key string) (int64, bool) {
+	v := c.Query(key)
+	if v == "" {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+func parseQueryTime(c *gin.Context, key string) (time.Time, bool) {
+	v := c.Query(key)
+	if v == "" {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
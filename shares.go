@@ -0,0 +1,489 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// Share is a signed link that lets a visitor access a single file or
+// directory inside ServeDir without logging in, optionally gated by a
+// password, an expiry time, and/or a download limit.
+type Share struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	Token         string     `json:"token" gorm:"unique;not null"`
+	Path          string     `json:"path" gorm:"not null"`
+	OwnerID       uint       `json:"ownerId" gorm:"not null"`
+	PasswordHash  string     `json:"-"`
+	Writable      bool       `json:"writable" gorm:"default:false"`
+	ExpiresAt     *time.Time `json:"expiresAt"`
+	MaxDownloads  int        `json:"maxDownloads"`
+	DownloadCount int        `json:"downloadCount" gorm:"default:0"`
+	CreatedAt     time.Time  `json:"createdAt"`
+}
+
+type CreateShareRequest struct {
+	Path         string     `json:"path" binding:"required"`
+	Password     string     `json:"password"`
+	Writable     bool       `json:"writable"`
+	ExpiresAt    *time.Time `json:"expiresAt"`
+	MaxDownloads int        `json:"maxDownloads"`
+}
+
+type UpdateShareRequest struct {
+	Password     *string    `json:"password"`
+	Writable     *bool      `json:"writable"`
+	ExpiresAt    *time.Time `json:"expiresAt"`
+	MaxDownloads *int       `json:"maxDownloads"`
+}
+
+type UnlockShareRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+var (
+	errShareExpired   = errors.New("share expired")
+	errShareExhausted = errors.New("share download limit reached")
+)
+
+// generateShareToken returns a URL-safe, crypto/rand-backed token with at
+// least 24 bytes of entropy.
+func generateShareToken() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func (s *Server) createShare(c *gin.Context) {
+	var req CreateShareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	requestPath := strings.TrimPrefix(req.Path, "/")
+	if _, err := s.Storage.Stat(requestPath); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Path not found"})
+		return
+	}
+
+	user, _ := c.Get("user")
+	claims := user.(*Claims)
+
+	if !s.hasPermission(claims, requestPath, PermShare) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions for this path"})
+		return
+	}
+
+	token, err := generateShareToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate share token"})
+		return
+	}
+
+	share := Share{
+		Token:        token,
+		Path:         requestPath,
+		OwnerID:      claims.UserID,
+		Writable:     req.Writable,
+		ExpiresAt:    req.ExpiresAt,
+		MaxDownloads: req.MaxDownloads,
+	}
+
+	if req.Password != "" {
+		hashed, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash share password"})
+			return
+		}
+		share.PasswordHash = string(hashed)
+	}
+
+	if result := s.DB.Create(&share); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create share"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, share)
+}
+
+func (s *Server) getShares(c *gin.Context) {
+	user, _ := c.Get("user")
+	claims := user.(*Claims)
+
+	var shares []Share
+	query := s.DB
+	if !claims.IsAdmin {
+		query = query.Where("owner_id = ?", claims.UserID)
+	}
+	if result := query.Find(&shares); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch shares"})
+		return
+	}
+
+	c.JSON(http.StatusOK, shares)
+}
+
+func (s *Server) loadOwnedShare(c *gin.Context) (*Share, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid share ID"})
+		return nil, false
+	}
+
+	var share Share
+	if result := s.DB.First(&share, id); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share not found"})
+		return nil, false
+	}
+
+	user, _ := c.Get("user")
+	claims := user.(*Claims)
+	if share.OwnerID != claims.UserID && !claims.IsAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to modify this share"})
+		return nil, false
+	}
+
+	return &share, true
+}
+
+func (s *Server) updateShare(c *gin.Context) {
+	share, ok := s.loadOwnedShare(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateShareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if req.Password != nil {
+		if *req.Password == "" {
+			share.PasswordHash = ""
+		} else {
+			hashed, err := bcrypt.GenerateFromPassword([]byte(*req.Password), bcrypt.DefaultCost)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash share password"})
+				return
+			}
+			share.PasswordHash = string(hashed)
+		}
+	}
+	if req.Writable != nil {
+		share.Writable = *req.Writable
+	}
+	if req.ExpiresAt != nil {
+		share.ExpiresAt = req.ExpiresAt
+	}
+	if req.MaxDownloads != nil {
+		share.MaxDownloads = *req.MaxDownloads
+	}
+
+	if result := s.DB.Save(share); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update share"})
+		return
+	}
+
+	c.JSON(http.StatusOK, share)
+}
+
+func (s *Server) deleteShare(c *gin.Context) {
+	share, ok := s.loadOwnedShare(c)
+	if !ok {
+		return
+	}
+
+	if result := s.DB.Delete(share); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete share"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Share deleted successfully"})
+}
+
+// lookupShare fetches a share by its public token and checks expiry and
+// download-limit constraints that apply regardless of the password.
+func (s *Server) lookupShare(token string) (*Share, error) {
+	var share Share
+	if result := s.DB.Where("token = ?", token).First(&share); result.Error != nil {
+		return nil, result.Error
+	}
+
+	if share.ExpiresAt != nil && time.Now().After(*share.ExpiresAt) {
+		return nil, errShareExpired
+	}
+	if share.MaxDownloads > 0 && share.DownloadCount >= share.MaxDownloads {
+		return nil, errShareExhausted
+	}
+
+	return &share, nil
+}
+
+// shareUnlocked reports whether the request may access share: true if the
+// share has no password, or if the Authorization header carries a valid
+// share-scoped JWT minted by unlockShare for this exact share.
+func (s *Server) shareUnlocked(c *gin.Context, share *Share) bool {
+	if share.PasswordHash == "" {
+		return true
+	}
+
+	tokenString := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if tokenString == "" {
+		return false
+	}
+
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return s.JWTKey, nil
+	})
+	if err != nil || !token.Valid {
+		return false
+	}
+
+	return claims.Scope == "share" && claims.ShareID == share.ID
+}
+
+// sharePath resolves subPath (the :path wildcard of a /s/:token/... route)
+// against share.Path and rejects anything that would escape the shared
+// directory, the same way DiskProvider.resolve rejects an on-disk escape.
+func sharePath(share *Share, subPath string) (string, error) {
+	sub := strings.TrimPrefix(subPath, "/")
+	full := filepath.Join(share.Path, sub)
+	if share.Path == "" {
+		// A share of the storage root contains everything except ".." paths.
+		if full == ".." || strings.HasPrefix(full, "../") {
+			return "", fmt.Errorf("access denied: path escapes shared directory")
+		}
+		return full, nil
+	}
+	if full != share.Path && !strings.HasPrefix(full, share.Path+"/") {
+		return "", fmt.Errorf("access denied: path escapes shared directory")
+	}
+	return full, nil
+}
+
+func (s *Server) getShare(c *gin.Context) {
+	share, err := s.lookupShare(c.Param("token"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share not found or expired"})
+		return
+	}
+
+	if !s.shareUnlocked(c, share) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Share is password protected", "locked": true})
+		return
+	}
+
+	target, err := sharePath(share, c.Param("path"))
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	stat, err := s.Storage.Stat(target)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shared path not found"})
+		return
+	}
+
+	if !stat.IsDir {
+		c.JSON(http.StatusOK, gin.H{"path": target, "writable": share.Writable, "file": stat})
+		return
+	}
+
+	files, err := s.Storage.List(target)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read shared directory"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"path": target, "writable": share.Writable, "files": files})
+}
+
+func (s *Server) downloadShare(c *gin.Context) {
+	share, err := s.lookupShare(c.Param("token"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share not found or expired"})
+		return
+	}
+
+	if !s.shareUnlocked(c, share) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Share is password protected", "locked": true})
+		return
+	}
+
+	target, err := sharePath(share, c.Param("path"))
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	stat, err := s.Storage.Stat(target)
+	if err != nil || stat.IsDir {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Share does not point to a downloadable file"})
+		return
+	}
+
+	reader, err := s.Storage.Open(target)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	defer reader.Close()
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filepath.Base(target)))
+	c.Header("Content-Length", strconv.FormatInt(stat.Size, 10))
+	c.Status(http.StatusOK)
+
+	if _, err := io.Copy(c.Writer, reader); err != nil {
+		log.Printf("Error streaming share download of %s: %v", target, err)
+		return
+	}
+
+	if result := s.DB.Model(&Share{}).Where("id = ?", share.ID).
+		Update("download_count", gorm.Expr("download_count + 1")); result.Error != nil {
+		log.Printf("Error recording download count for share %d: %v", share.ID, result.Error)
+	}
+}
+
+// uploadToShare writes the request body to subPath inside a writable share,
+// creating the file (or overwriting it). Unlike the authenticated /api/uploads
+// routes, this is a single-shot write: anonymous share visitors aren't
+// tracked the way tus-style resumable uploads track a UserID.
+func (s *Server) uploadToShare(c *gin.Context) {
+	share, ok := s.loadWritableShare(c)
+	if !ok {
+		return
+	}
+
+	target, err := sharePath(share, c.Param("path"))
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if target == share.Path {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A file name is required"})
+		return
+	}
+
+	out, err := s.Storage.Create(target)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create file"})
+		return
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, c.Request.Body); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write file"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "File uploaded successfully"})
+}
+
+// deleteFromShare removes subPath from a writable share.
+func (s *Server) deleteFromShare(c *gin.Context) {
+	share, ok := s.loadWritableShare(c)
+	if !ok {
+		return
+	}
+
+	target, err := sharePath(share, c.Param("path"))
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if target == share.Path {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot delete the shared path itself"})
+		return
+	}
+
+	if err := s.Storage.Remove(target); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete file"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "File deleted successfully"})
+}
+
+// loadWritableShare looks up the share named by the :token route param,
+// checks it's unlocked, and rejects the request unless the share was
+// explicitly created with Writable: true.
+func (s *Server) loadWritableShare(c *gin.Context) (*Share, bool) {
+	share, err := s.lookupShare(c.Param("token"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share not found or expired"})
+		return nil, false
+	}
+
+	if !s.shareUnlocked(c, share) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Share is password protected", "locked": true})
+		return nil, false
+	}
+
+	if !share.Writable {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Share is read-only"})
+		return nil, false
+	}
+
+	return share, true
+}
+
+func (s *Server) unlockShare(c *gin.Context) {
+	share, err := s.lookupShare(c.Param("token"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share not found or expired"})
+		return
+	}
+
+	if share.PasswordHash == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Share is not password protected"})
+		return
+	}
+
+	var req UnlockShareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(share.PasswordHash), []byte(req.Password)); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Incorrect password"})
+		return
+	}
+
+	claims := Claims{
+		ShareID: share.ID,
+		Scope:   "share",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(15 * time.Minute)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	signed, err := token.SignedString(s.JWTKey)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate unlock token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"token": signed})
+}
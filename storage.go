@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+)
+
+// StorageProvider abstracts the filesystem operations used by the HTTP
+// handlers so the same REST API can front heterogeneous backends (local
+// disk, S3, Backblaze B2, ...). All paths passed to a provider are relative
+// to that provider's root/mount point, never absolute on-disk paths.
+type StorageProvider interface {
+	// Name identifies the provider, e.g. "disk" or "s3". It is stamped onto
+	// FileInfo.Provider so indexed results can be routed back here.
+	Name() string
+
+	Stat(path string) (FileInfo, error)
+	List(path string) ([]FileInfo, error)
+	Walk(root string, walkFn func(FileInfo) error) error
+
+	// Open returns a streaming handle to the file contents; callers are
+	// responsible for closing it.
+	Open(path string) (io.ReadCloser, error)
+	// Create returns a streaming handle that writes to path, creating any
+	// missing parent directories.
+	Create(path string) (io.WriteCloser, error)
+
+	Rename(oldPath, newPath string) error
+	Remove(path string) error
+	Mkdir(path string) error
+}
+
+// MountedProvider pairs a StorageProvider with the URL prefix it's mounted
+// under, e.g. a Backblaze bucket mounted at "/archive".
+type MountedProvider struct {
+	Mount    string
+	Provider StorageProvider
+}
+
+// MultiProvider dispatches to one of several mounted providers based on the
+// longest matching mount prefix, so Server can treat a set of heterogeneous
+// backends as a single StorageProvider.
+type MultiProvider struct {
+	mounts []MountedProvider
+}
+
+func NewMultiProvider(mounts []MountedProvider) *MultiProvider {
+	return &MultiProvider{mounts: mounts}
+}
+
+func (m *MultiProvider) Name() string { return "multi" }
+
+// Mounts exposes the configured mounts so callers that need provider-
+// specific behavior (e.g. the fsnotify indexer, which can only watch
+// DiskProvider mounts) can inspect them directly.
+func (m *MultiProvider) Mounts() []MountedProvider {
+	return m.mounts
+}
+
+// resolve finds the mount with the longest matching prefix for path and
+// returns the provider plus the path relative to that mount's root.
+func (m *MultiProvider) resolve(path string) (StorageProvider, string, error) {
+	var best *MountedProvider
+	for i := range m.mounts {
+		mount := &m.mounts[i]
+		if mount.Mount == "/" || mount.Mount == "" {
+			if best == nil {
+				best = mount
+			}
+			continue
+		}
+		if pathHasMountPrefix(path, mount.Mount) {
+			if best == nil || len(mount.Mount) > len(best.Mount) {
+				best = mount
+			}
+		}
+	}
+	if best == nil {
+		return nil, "", fmt.Errorf("no storage provider mounted for path %q", path)
+	}
+	return best.Provider, stripMountPrefix(path, best.Mount), nil
+}
+
+func (m *MultiProvider) Stat(path string) (FileInfo, error) {
+	provider, rel, err := m.resolve(path)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	return provider.Stat(rel)
+}
+
+func (m *MultiProvider) List(path string) ([]FileInfo, error) {
+	provider, rel, err := m.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+	return provider.List(rel)
+}
+
+// Walk walks every mounted provider when root is empty (the root of the
+// combined tree), prefixing each result's path fields with the mount point
+// so paths returned stay addressable through MultiProvider itself. A
+// non-empty root walks only the single mount it resolves to.
+func (m *MultiProvider) Walk(root string, walkFn func(FileInfo) error) error {
+	if trimSlashes(root) != "" {
+		provider, rel, err := m.resolve(root)
+		if err != nil {
+			return err
+		}
+		return provider.Walk(rel, walkFn)
+	}
+
+	for i := range m.mounts {
+		mount := &m.mounts[i]
+		err := mount.Provider.Walk("", func(info FileInfo) error {
+			info.Path = prefixWithMount(mount.Mount, info.Path)
+			info.RelativePath = prefixWithMount(mount.Mount, info.RelativePath)
+			return walkFn(info)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MultiProvider) Open(path string) (io.ReadCloser, error) {
+	provider, rel, err := m.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+	return provider.Open(rel)
+}
+
+func (m *MultiProvider) Create(path string) (io.WriteCloser, error) {
+	provider, rel, err := m.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+	return provider.Create(rel)
+}
+
+func (m *MultiProvider) Rename(oldPath, newPath string) error {
+	provider, rel, err := m.resolve(oldPath)
+	if err != nil {
+		return err
+	}
+	newProvider, newRel, err := m.resolve(newPath)
+	if err != nil {
+		return err
+	}
+	if newProvider != provider {
+		return fmt.Errorf("cannot rename across storage providers")
+	}
+	return provider.Rename(rel, newRel)
+}
+
+func (m *MultiProvider) Remove(path string) error {
+	provider, rel, err := m.resolve(path)
+	if err != nil {
+		return err
+	}
+	return provider.Remove(rel)
+}
+
+func (m *MultiProvider) Mkdir(path string) error {
+	provider, rel, err := m.resolve(path)
+	if err != nil {
+		return err
+	}
+	return provider.Mkdir(rel)
+}
+
+func pathHasMountPrefix(path, mount string) bool {
+	mount = trimSlashes(mount)
+	path = trimSlashes(path)
+	if mount == "" {
+		return true
+	}
+	return path == mount || len(path) > len(mount) && path[:len(mount)+1] == mount+"/"
+}
+
+func stripMountPrefix(path, mount string) string {
+	mount = trimSlashes(mount)
+	path = trimSlashes(path)
+	if mount == "" {
+		return path
+	}
+	rel := path[len(mount):]
+	return trimSlashes(rel)
+}
+
+// prefixWithMount joins mount onto path the way MultiProvider.Walk's results
+// need to be keyed so they stay addressable through MultiProvider itself;
+// the root mount ("" or "/") contributes no prefix.
+func prefixWithMount(mount, path string) string {
+	if mount == "" || mount == "/" {
+		return path
+	}
+	return filepath.Join(mount, path)
+}
+
+func trimSlashes(s string) string {
+	for len(s) > 0 && s[0] == '/' {
+		s = s[1:]
+	}
+	for len(s) > 0 && s[len(s)-1] == '/' {
+		s = s[:len(s)-1]
+	}
+	return s
+}
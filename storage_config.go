@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProviderEntry describes a single mounted StorageProvider read from
+// providers.yml, e.g.:
+//
+//	providers:
+//	  - name: local
+//	    type: disk
+//	    mount: /
+//	    path: ./data
+//	  - name: archive
+//	    type: s3
+//	    mount: /archive
+//	    bucket: my-bucket
+//	    region: us-east-1
+//	    accessKeyId: ...
+//	    secretAccessKey: ...
+type ProviderEntry struct {
+	Name            string `yaml:"name"`
+	Type            string `yaml:"type"`
+	Mount           string `yaml:"mount"`
+	Path            string `yaml:"path"`
+	Bucket          string `yaml:"bucket"`
+	Region          string `yaml:"region"`
+	Endpoint        string `yaml:"endpoint"`
+	Prefix          string `yaml:"prefix"`
+	AccessKeyID     string `yaml:"accessKeyId"`
+	SecretAccessKey string `yaml:"secretAccessKey"`
+}
+
+type ProvidersConfig struct {
+	Providers []ProviderEntry `yaml:"providers"`
+}
+
+// loadProvidersConfig reads providers.yml. If the file doesn't exist, it
+// returns a config with a single disk provider mounted at "/" pointing at
+// serveDir, matching the server's historical single-directory behavior.
+func loadProvidersConfig(configPath string, serveDir string) (*ProvidersConfig, error) {
+	data, err := os.ReadFile(configPath)
+	if os.IsNotExist(err) {
+		return &ProvidersConfig{
+			Providers: []ProviderEntry{
+				{Name: "local", Type: "disk", Mount: "/", Path: serveDir},
+			},
+		}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", configPath, err)
+	}
+
+	var cfg ProvidersConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", configPath, err)
+	}
+	if len(cfg.Providers) == 0 {
+		return nil, fmt.Errorf("%s: no providers configured", configPath)
+	}
+
+	return &cfg, nil
+}
+
+// buildStorage instantiates a StorageProvider for each configured entry and
+// wraps them in a MultiProvider keyed by mount point.
+func buildStorage(ctx context.Context, cfg *ProvidersConfig) (StorageProvider, error) {
+	mounts := make([]MountedProvider, 0, len(cfg.Providers))
+
+	for _, entry := range cfg.Providers {
+		var provider StorageProvider
+
+		switch entry.Type {
+		case "disk", "":
+			if entry.Path == "" {
+				return nil, fmt.Errorf("provider %q: disk type requires path", entry.Name)
+			}
+			provider = NewDiskProvider(entry.Path)
+		case "s3", "b2":
+			s3Provider, err := NewS3Provider(ctx, S3ProviderConfig{
+				Bucket:          entry.Bucket,
+				Region:          entry.Region,
+				Endpoint:        entry.Endpoint,
+				Prefix:          entry.Prefix,
+				AccessKeyID:     entry.AccessKeyID,
+				SecretAccessKey: entry.SecretAccessKey,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("provider %q: %w", entry.Name, err)
+			}
+			provider = s3Provider
+		default:
+			return nil, fmt.Errorf("provider %q: unknown type %q", entry.Name, entry.Type)
+		}
+
+		mounts = append(mounts, MountedProvider{Mount: entry.Mount, Provider: provider})
+	}
+
+	return NewMultiProvider(mounts), nil
+}
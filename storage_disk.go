@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DiskProvider is the default StorageProvider, backed by a directory on the
+// local filesystem. It preserves the symlink-aware behavior of getFileInfo.
+type DiskProvider struct {
+	Root string
+}
+
+func NewDiskProvider(root string) *DiskProvider {
+	return &DiskProvider{Root: filepath.Clean(root)}
+}
+
+func (d *DiskProvider) Name() string { return "disk" }
+
+// resolve joins path onto Root and rejects anything that escapes it, e.g.
+// via a leading "../".
+func (d *DiskProvider) resolve(path string) (string, error) {
+	full := filepath.Join(d.Root, path)
+	if full != d.Root && !strings.HasPrefix(full, d.Root+string(os.PathSeparator)) {
+		return "", fmt.Errorf("access denied: path escapes storage root")
+	}
+	return full, nil
+}
+
+func (d *DiskProvider) Stat(path string) (FileInfo, error) {
+	full, err := d.resolve(path)
+	if err != nil {
+		return FileInfo{}, err
+	}
+
+	info, err := getFileInfo(full, filepath.Base(full), path)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	info.Provider = d.Name()
+	return info, nil
+}
+
+func (d *DiskProvider) List(path string) ([]FileInfo, error) {
+	full, err := d.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(full)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		relativePath := filepath.Join(path, entry.Name())
+		entryPath := filepath.Join(full, entry.Name())
+
+		fileInfo, err := getFileInfo(entryPath, entry.Name(), relativePath)
+		if err != nil {
+			continue // Skip files with errors
+		}
+		fileInfo.Provider = d.Name()
+		files = append(files, fileInfo)
+	}
+
+	return files, nil
+}
+
+func (d *DiskProvider) Walk(root string, walkFn func(FileInfo) error) error {
+	full, err := d.resolve(root)
+	if err != nil {
+		return err
+	}
+
+	return filepath.Walk(full, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil // Skip files with errors
+		}
+
+		relativePath, _ := filepath.Rel(d.Root, path)
+		if relativePath == "." {
+			return nil // Skip root directory
+		}
+
+		fileInfo, err := getFileInfo(path, info.Name(), relativePath)
+		if err != nil {
+			return nil // Skip files with errors
+		}
+		fileInfo.Provider = d.Name()
+
+		return walkFn(fileInfo)
+	})
+}
+
+func (d *DiskProvider) Open(path string) (io.ReadCloser, error) {
+	full, err := d.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(full)
+}
+
+func (d *DiskProvider) Create(path string) (io.WriteCloser, error) {
+	full, err := d.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+		return nil, err
+	}
+	return os.Create(full)
+}
+
+func (d *DiskProvider) Rename(oldPath, newPath string) error {
+	oldFull, err := d.resolve(oldPath)
+	if err != nil {
+		return err
+	}
+	newFull, err := d.resolve(newPath)
+	if err != nil {
+		return err
+	}
+	return os.Rename(oldFull, newFull)
+}
+
+func (d *DiskProvider) Remove(path string) error {
+	full, err := d.resolve(path)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(full)
+}
+
+func (d *DiskProvider) Mkdir(path string) error {
+	full, err := d.resolve(path)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(full, 0755)
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// S3Provider is a StorageProvider backed by an S3-compatible bucket
+// (AWS S3 or Backblaze B2's S3-compatible API, selected via Endpoint).
+// Directories are simulated with "/"-delimited keys: Mkdir writes a
+// zero-byte object with a trailing slash and List/Stat treat any key
+// prefix with children as a directory.
+type S3Provider struct {
+	client *s3.Client
+	bucket string
+	prefix string
+}
+
+// S3ProviderConfig holds the type-specific credentials read from
+// providers.yml for a provider entry with type "s3" or "b2".
+type S3ProviderConfig struct {
+	Bucket          string
+	Region          string
+	Endpoint        string
+	Prefix          string
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+func NewS3Provider(ctx context.Context, cfg S3ProviderConfig) (*S3Provider, error) {
+	awsCfg, err := config.LoadDefaultConfig(ctx,
+		config.WithRegion(cfg.Region),
+		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(
+			cfg.AccessKeyID, cfg.SecretAccessKey, "")),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("loading s3 config: %w", err)
+	}
+
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+			o.UsePathStyle = true
+		}
+	})
+
+	return &S3Provider{
+		client: client,
+		bucket: cfg.Bucket,
+		prefix: strings.Trim(cfg.Prefix, "/"),
+	}, nil
+}
+
+func (p *S3Provider) Name() string { return "s3" }
+
+func (p *S3Provider) key(relPath string) string {
+	relPath = strings.Trim(relPath, "/")
+	if p.prefix == "" {
+		return relPath
+	}
+	if relPath == "" {
+		return p.prefix
+	}
+	return p.prefix + "/" + relPath
+}
+
+func (p *S3Provider) Stat(relPath string) (FileInfo, error) {
+	ctx := context.Background()
+	key := p.key(relPath)
+
+	head, err := p.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(p.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		// No object at that exact key; treat it as a directory if it has
+		// at least one child under the prefix.
+		listPrefix := key
+		if listPrefix != "" {
+			listPrefix += "/"
+		}
+		listOut, listErr := p.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+			Bucket:  aws.String(p.bucket),
+			Prefix:  aws.String(listPrefix),
+			MaxKeys: aws.Int32(1),
+		})
+		if listErr != nil || len(listOut.Contents) == 0 {
+			return FileInfo{}, fmt.Errorf("object not found: %s", relPath)
+		}
+		return FileInfo{
+			Name:         path.Base(relPath),
+			Path:         key,
+			RelativePath: relPath,
+			IsDir:        true,
+			Provider:     p.Name(),
+		}, nil
+	}
+
+	return FileInfo{
+		Name:         path.Base(relPath),
+		Path:         key,
+		RelativePath: relPath,
+		Size:         aws.ToInt64(head.ContentLength),
+		ModTime:      aws.ToTime(head.LastModified),
+		Extension:    strings.ToLower(path.Ext(relPath)),
+		Provider:     p.Name(),
+	}, nil
+}
+
+func (p *S3Provider) List(relPath string) ([]FileInfo, error) {
+	ctx := context.Background()
+	prefix := p.key(relPath)
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	out, err := p.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket:    aws.String(p.bucket),
+		Prefix:    aws.String(prefix),
+		Delimiter: aws.String("/"),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]FileInfo, 0, len(out.Contents)+len(out.CommonPrefixes))
+	for _, cp := range out.CommonPrefixes {
+		name := strings.TrimSuffix(strings.TrimPrefix(aws.ToString(cp.Prefix), prefix), "/")
+		files = append(files, FileInfo{
+			Name:         name,
+			Path:         strings.TrimSuffix(aws.ToString(cp.Prefix), "/"),
+			RelativePath: path.Join(relPath, name),
+			IsDir:        true,
+			Provider:     p.Name(),
+		})
+	}
+	for _, obj := range out.Contents {
+		key := aws.ToString(obj.Key)
+		name := strings.TrimPrefix(key, prefix)
+		if name == "" {
+			continue // the directory marker object itself
+		}
+		files = append(files, FileInfo{
+			Name:         name,
+			Path:         key,
+			RelativePath: path.Join(relPath, name),
+			Size:         aws.ToInt64(obj.Size),
+			ModTime:      aws.ToTime(obj.LastModified),
+			Extension:    strings.ToLower(path.Ext(name)),
+			Provider:     p.Name(),
+		})
+	}
+
+	return files, nil
+}
+
+func (p *S3Provider) Walk(root string, walkFn func(FileInfo) error) error {
+	ctx := context.Background()
+	prefix := p.key(root)
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	var continuationToken *string
+	for {
+		out, err := p.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+			Bucket:            aws.String(p.bucket),
+			Prefix:            aws.String(prefix),
+			ContinuationToken: continuationToken,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, obj := range out.Contents {
+			key := aws.ToString(obj.Key)
+			relativePath := strings.TrimPrefix(key, prefix)
+			if relativePath == "" {
+				continue
+			}
+			if err := walkFn(FileInfo{
+				Name:         path.Base(relativePath),
+				Path:         key,
+				RelativePath: relativePath,
+				Size:         aws.ToInt64(obj.Size),
+				ModTime:      aws.ToTime(obj.LastModified),
+				Extension:    strings.ToLower(path.Ext(relativePath)),
+				Provider:     p.Name(),
+			}); err != nil {
+				return err
+			}
+		}
+
+		if !aws.ToBool(out.IsTruncated) {
+			break
+		}
+		continuationToken = out.NextContinuationToken
+	}
+
+	return nil
+}
+
+func (p *S3Provider) Open(relPath string) (io.ReadCloser, error) {
+	ctx := context.Background()
+	out, err := p.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(p.bucket),
+		Key:    aws.String(p.key(relPath)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+// s3Writer buffers the uploaded contents into an io.Pipe and streams them
+// to PutObject on a background goroutine so callers don't need to know the
+// final size up front.
+type s3Writer struct {
+	pw   *io.PipeWriter
+	done chan error
+}
+
+func (w *s3Writer) Write(p []byte) (int, error) { return w.pw.Write(p) }
+
+func (w *s3Writer) Close() error {
+	if err := w.pw.Close(); err != nil {
+		return err
+	}
+	return <-w.done
+}
+
+func (p *S3Provider) Create(relPath string) (io.WriteCloser, error) {
+	pr, pw := io.Pipe()
+	w := &s3Writer{pw: pw, done: make(chan error, 1)}
+
+	go func() {
+		_, err := p.client.PutObject(context.Background(), &s3.PutObjectInput{
+			Bucket: aws.String(p.bucket),
+			Key:    aws.String(p.key(relPath)),
+			Body:   pr,
+		})
+		pr.CloseWithError(err)
+		w.done <- err
+	}()
+
+	return w, nil
+}
+
+func (p *S3Provider) Rename(oldPath, newPath string) error {
+	ctx := context.Background()
+	src := p.bucket + "/" + p.key(oldPath)
+
+	if _, err := p.client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(p.bucket),
+		Key:        aws.String(p.key(newPath)),
+		CopySource: aws.String(src),
+	}); err != nil {
+		return fmt.Errorf("copying object for rename: %w", err)
+	}
+
+	return p.Remove(oldPath)
+}
+
+func (p *S3Provider) Remove(relPath string) error {
+	ctx := context.Background()
+	_, err := p.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(p.bucket),
+		Key:    aws.String(p.key(relPath)),
+	})
+	return err
+}
+
+func (p *S3Provider) Mkdir(relPath string) error {
+	ctx := context.Background()
+	_, err := p.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(p.bucket),
+		Key:    aws.String(p.key(relPath) + "/"),
+		Body:   strings.NewReader(""),
+	})
+	return err
+}
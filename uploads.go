@@ -0,0 +1,379 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Upload tracks an in-progress tus-style resumable upload so it can
+// survive a server restart: chunks are appended to <id>.part under
+// Server.UploadTempDir and only moved into storage once Offset reaches
+// TotalSize and the checksum (if given) matches.
+type Upload struct {
+	ID         string    `json:"id" gorm:"primaryKey"`
+	TargetPath string    `json:"targetPath" gorm:"not null"`
+	UserID     uint      `json:"userId" gorm:"not null"`
+	TotalSize  int64     `json:"totalSize" gorm:"not null"`
+	Offset     int64     `json:"offset" gorm:"default:0"`
+	Checksum   string    `json:"checksum"`
+	HashState  string    `json:"-"`
+	CreatedAt  time.Time `json:"createdAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+}
+
+type CreateUploadRequest struct {
+	Path      string `json:"path" binding:"required"`
+	TotalSize int64  `json:"totalSize" binding:"required"`
+	Checksum  string `json:"checksum"`
+}
+
+func generateUploadID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func (s *Server) uploadPartPath(id string) string {
+	return filepath.Join(s.UploadTempDir, id+".part")
+}
+
+// saveHashState snapshots hasher's internal state onto upload so it can be
+// resumed by a later chunk, even across a server restart.
+func (s *Server) saveHashState(upload *Upload, hasher hash.Hash) error {
+	marshaler, ok := hasher.(encoding.BinaryMarshaler)
+	if !ok {
+		return fmt.Errorf("hasher does not support state persistence")
+	}
+	state, err := marshaler.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	upload.HashState = base64.StdEncoding.EncodeToString(state)
+	return nil
+}
+
+// loadHashState restores the sha256 state saved by a previous chunk, or
+// returns a fresh hasher if this is the first chunk.
+func (s *Server) loadHashState(upload *Upload) (hash.Hash, error) {
+	hasher := sha256.New()
+	if upload.HashState == "" {
+		return hasher, nil
+	}
+
+	state, err := base64.StdEncoding.DecodeString(upload.HashState)
+	if err != nil {
+		return nil, err
+	}
+	unmarshaler, ok := hasher.(encoding.BinaryUnmarshaler)
+	if !ok {
+		return nil, fmt.Errorf("hasher does not support state restoration")
+	}
+	if err := unmarshaler.UnmarshalBinary(state); err != nil {
+		return nil, err
+	}
+
+	return hasher, nil
+}
+
+// diskProviderFor resolves the DiskProvider backing path, if any, so
+// completeUpload can finish the transfer with an atomic rename instead of
+// a stream copy when both the temp dir and target mount are local.
+func (s *Server) diskProviderFor(path string) (*DiskProvider, bool) {
+	if disk, ok := s.Storage.(*DiskProvider); ok {
+		return disk, true
+	}
+	multi, ok := s.Storage.(*MultiProvider)
+	if !ok {
+		return nil, false
+	}
+	provider, _, err := multi.resolve(path)
+	if err != nil {
+		return nil, false
+	}
+	disk, ok := provider.(*DiskProvider)
+	return disk, ok
+}
+
+func (s *Server) createUpload(c *gin.Context) {
+	var req CreateUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.TotalSize <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "totalSize must be positive"})
+		return
+	}
+	if s.MaxUploadSize > 0 && req.TotalSize > s.MaxUploadSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File exceeds the maximum upload size"})
+		return
+	}
+
+	user, _ := c.Get("user")
+	claims := user.(*Claims)
+
+	targetPath := strings.TrimPrefix(req.Path, "/")
+	if !s.hasPermission(claims, targetPath, PermWrite) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions for this path"})
+		return
+	}
+
+	if s.UserQuotaBytes > 0 {
+		var inFlight int64
+		s.DB.Model(&Upload{}).
+			Where("user_id = ? AND expires_at > ?", claims.UserID, time.Now()).
+			Select("COALESCE(SUM(total_size), 0)").Scan(&inFlight)
+		if inFlight+req.TotalSize > s.UserQuotaBytes {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Upload would exceed your quota"})
+			return
+		}
+	}
+
+	id, err := generateUploadID()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create upload"})
+		return
+	}
+
+	upload := Upload{
+		ID:         id,
+		TargetPath: targetPath,
+		UserID:     claims.UserID,
+		TotalSize:  req.TotalSize,
+		Checksum:   strings.ToLower(req.Checksum),
+		ExpiresAt:  time.Now().Add(s.UploadTTL),
+	}
+	if err := s.saveHashState(&upload, sha256.New()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to initialize upload"})
+		return
+	}
+
+	if result := s.DB.Create(&upload); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create upload"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"id":  upload.ID,
+		"url": "/api/uploads/" + upload.ID,
+	})
+}
+
+func (s *Server) loadOwnedUpload(c *gin.Context) (*Upload, bool) {
+	var upload Upload
+	if result := s.DB.First(&upload, "id = ?", c.Param("id")); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Upload not found"})
+		return nil, false
+	}
+
+	user, _ := c.Get("user")
+	claims := user.(*Claims)
+	if upload.UserID != claims.UserID && !claims.IsAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized for this upload"})
+		return nil, false
+	}
+
+	return &upload, true
+}
+
+func (s *Server) patchUpload(c *gin.Context) {
+	upload, ok := s.loadOwnedUpload(c)
+	if !ok {
+		return
+	}
+
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or missing Upload-Offset header"})
+		return
+	}
+	if offset != upload.Offset {
+		c.JSON(http.StatusConflict, gin.H{"error": "Upload-Offset does not match server state", "offset": upload.Offset})
+		return
+	}
+
+	part, err := os.OpenFile(s.uploadPartPath(upload.ID), os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open upload part"})
+		return
+	}
+	defer part.Close()
+
+	if _, err := part.Seek(offset, io.SeekStart); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to seek upload part"})
+		return
+	}
+
+	hasher, err := s.loadHashState(upload)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resume upload checksum"})
+		return
+	}
+
+	// Cap the write at exactly the remaining bytes so an over-long chunk
+	// can't land past TotalSize in the part file, where a later correctly
+	// sized retry at the same offset would never truncate it away.
+	remaining := upload.TotalSize - offset
+	written, err := io.Copy(io.MultiWriter(part, hasher), io.LimitReader(c.Request.Body, remaining))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write upload chunk"})
+		return
+	}
+
+	if written == remaining {
+		var extra [1]byte
+		if n, _ := c.Request.Body.Read(extra[:]); n > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Uploaded more bytes than totalSize"})
+			return
+		}
+	}
+
+	upload.Offset += written
+
+	if err := s.saveHashState(upload, hasher); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to persist upload checksum"})
+		return
+	}
+	if result := s.DB.Save(upload); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to persist upload progress"})
+		return
+	}
+
+	if upload.Offset < upload.TotalSize {
+		c.JSON(http.StatusOK, gin.H{"offset": upload.Offset, "complete": false})
+		return
+	}
+
+	if err := s.completeUpload(upload, hasher); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"offset": upload.Offset, "complete": true})
+}
+
+// completeUpload verifies the checksum (if one was supplied) and moves the
+// finished part file into storage, preferring an atomic rename when the
+// target is on the same disk as the upload temp dir.
+func (s *Server) completeUpload(upload *Upload, hasher hash.Hash) error {
+	if upload.Checksum != "" {
+		sum := hex.EncodeToString(hasher.Sum(nil))
+		if sum != upload.Checksum {
+			return fmt.Errorf("checksum mismatch: expected %s, got %s", upload.Checksum, sum)
+		}
+	}
+
+	partPath := s.uploadPartPath(upload.ID)
+
+	if disk, ok := s.diskProviderFor(upload.TargetPath); ok {
+		destPath, err := disk.resolve(upload.TargetPath)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
+			return err
+		}
+		if err := os.Rename(partPath, destPath); err != nil {
+			return err
+		}
+	} else {
+		part, err := os.Open(partPath)
+		if err != nil {
+			return err
+		}
+
+		out, err := s.Storage.Create(upload.TargetPath)
+		if err != nil {
+			part.Close()
+			return err
+		}
+
+		_, copyErr := io.Copy(out, part)
+		part.Close()
+		closeErr := out.Close()
+		os.Remove(partPath)
+		if copyErr != nil {
+			return copyErr
+		}
+		if closeErr != nil {
+			return closeErr
+		}
+	}
+
+	if result := s.DB.Delete(upload); result.Error != nil {
+		return result.Error
+	}
+
+	s.indexCompletedUpload(upload.TargetPath)
+
+	return nil
+}
+
+// indexCompletedUpload updates the index and, if enabled, the content index
+// for a single just-completed upload, the same way handleFSEvent updates
+// them for a local filesystem change. A disk-backed completion is usually
+// also picked up by the fsnotify watcher, but this keeps the index current
+// immediately, and is the only path that sees a remote-provider upload at
+// all since fsnotify can't watch those.
+func (s *Server) indexCompletedUpload(targetPath string) {
+	info, err := s.Storage.Stat(targetPath)
+	if err != nil {
+		log.Printf("Failed to stat completed upload %s for indexing: %v", targetPath, err)
+		return
+	}
+
+	s.Index.put(info)
+	if s.IndexContent && !info.IsDir {
+		indexFileContent(s.Storage, s.ContentIndex, s.SearchIgnore, info)
+	}
+	s.Index.touch()
+
+	s.Events.publish(IndexEvent{Type: "write", Path: targetPath, Info: &info})
+}
+
+func (s *Server) headUpload(c *gin.Context) {
+	upload, ok := s.loadOwnedUpload(c)
+	if !ok {
+		return
+	}
+
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(upload.TotalSize, 10))
+	c.Status(http.StatusOK)
+}
+
+func (s *Server) deleteUpload(c *gin.Context) {
+	upload, ok := s.loadOwnedUpload(c)
+	if !ok {
+		return
+	}
+
+	if err := os.Remove(s.uploadPartPath(upload.ID)); err != nil && !os.IsNotExist(err) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove upload part"})
+		return
+	}
+	if result := s.DB.Delete(upload); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to abort upload"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Upload aborted"})
+}